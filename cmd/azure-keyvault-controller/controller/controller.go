@@ -0,0 +1,390 @@
+/*
+Copyright Sparebanken Vest
+
+Based on the Kubernetes controller example at
+https://github.com/kubernetes/sample-controller
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package controller reconciles AzureKeyVaultSecrets: for every one it
+// owns (see Options.ShardAssignment), it fetches the referenced Key Vault
+// object as a secret and upserts it into the configured Kubernetes Secret.
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/azure/credentialprovider"
+	vault "github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/azure/keyvault/client"
+	akvv1alpha1 "github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/k8s/apis/azurekeyvault/v1alpha1"
+	clientset "github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/k8s/client/clientset/versioned"
+	informers "github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/k8s/client/informers/externalversions"
+	listers "github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/k8s/client/listers/azurekeyvault/v1alpha1"
+	"github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/sharding"
+)
+
+// AzurePollFrequency holds the poll intervals for AzureKeyVaultSecrets and
+// the failure count after which polling slows down. The Controller keeps
+// these values (UpdateReloadableConfig replaces Normal and Slow) but
+// schedules no re-enqueue from them: AzureKeyVaultSecrets are enqueued
+// only by informer add/update/delete events and by EnqueueFromVaultEvent.
+type AzurePollFrequency struct {
+	Normal                       time.Duration
+	Slow                         time.Duration
+	MaxFailuresBeforeSlowingDown int
+}
+
+// Options holds the less central Controller settings that don't have an
+// obvious home in the long positional NewController argument list.
+type Options struct {
+	MaxNumRequeues int
+	NumThreads     int
+
+	CABundleConfigMapName string
+
+	// ShardAssignment, when non-nil, restricts this Controller to only
+	// reconciling the AzureKeyVaultSecrets it owns (see
+	// sharding.Assignment.Owns), so a fleet of replicas can split the
+	// work instead of every replica reconciling every secret.
+	ShardAssignment *sharding.Assignment
+
+	// VaultServiceFactory is called once per reconcile with the
+	// AzureKeyVaultSecret's spec.vault.auth (nil for secrets that don't
+	// set an override) and returns the *vault.Service to fetch that
+	// secret's value from.
+	VaultServiceFactory func(override *credentialprovider.VaultAuthSelector) (*vault.Service, error)
+}
+
+// Controller watches AzureKeyVaultSecrets and syncs the Key Vault object
+// each one references into a Kubernetes Secret.
+type Controller struct {
+	kubeclientset kubernetes.Interface
+	akvsclientset clientset.Interface
+
+	akvsLister listers.AzureKeyVaultSecretLister
+	akvsSynced cache.InformerSynced
+
+	vaultService *vault.Service
+	recorder     record.EventRecorder
+
+	workqueue workqueue.RateLimitingInterface
+
+	maxNumRequeues int
+	numThreads     int
+
+	caBundleConfigMapName string
+	shardAssignment       *sharding.Assignment
+	vaultServiceFactory   func(override *credentialprovider.VaultAuthSelector) (*vault.Service, error)
+
+	// mu guards the reloadable fields below, which config.WatchForReload
+	// can update concurrently with syncHandler reading them.
+	mu           sync.RWMutex
+	akvLabelName string
+	pollFreq     AzurePollFrequency
+}
+
+// NewController creates a Controller. The returned Controller does not
+// start reconciling until Run is called.
+func NewController(
+	kubeclientset kubernetes.Interface,
+	akvsclientset clientset.Interface,
+	akvsInformerFactory informers.SharedInformerFactory,
+	kubeInformerFactory kubeinformers.SharedInformerFactory,
+	recorder record.EventRecorder,
+	vaultService *vault.Service,
+	secretName, namespace, akvLabelName string,
+	pollFreq AzurePollFrequency,
+	opts *Options,
+) *Controller {
+	akvsInformer := akvsInformerFactory.Azurekeyvault().V1alpha1().AzureKeyVaultSecrets()
+
+	c := &Controller{
+		kubeclientset:         kubeclientset,
+		akvsclientset:         akvsclientset,
+		akvsLister:            akvsInformer.Lister(),
+		akvsSynced:            akvsInformer.Informer().HasSynced,
+		vaultService:          vaultService,
+		recorder:              recorder,
+		workqueue:             workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		maxNumRequeues:        opts.MaxNumRequeues,
+		numThreads:            opts.NumThreads,
+		caBundleConfigMapName: opts.CABundleConfigMapName,
+		shardAssignment:       opts.ShardAssignment,
+		vaultServiceFactory:   opts.VaultServiceFactory,
+		akvLabelName:          akvLabelName,
+		pollFreq:              pollFreq,
+	}
+
+	log.Info("setting up event handlers")
+	akvsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueAzureKeyVaultSecret,
+		UpdateFunc: func(old, new interface{}) {
+			c.enqueueAzureKeyVaultSecret(new)
+		},
+		DeleteFunc: c.enqueueAzureKeyVaultSecret,
+	})
+
+	return c
+}
+
+// Run starts numThreads worker goroutines processing the workqueue and
+// blocks until stopCh is closed.
+func (c *Controller) Run(stopCh <-chan struct{}) error {
+	defer runtime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	log.Info("starting azurekeyvaultsecret controller")
+
+	log.Info("waiting for informer caches to sync")
+	if ok := cache.WaitForCacheSync(stopCh, c.akvsSynced); !ok {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	log.Infof("starting %d workers", c.numThreads)
+	for i := 0; i < c.numThreads; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+
+	log.Info("started workers")
+	<-stopCh
+	log.Info("shutting down workers")
+
+	return nil
+}
+
+// UpdateReloadableConfig swaps in config.WatchForReload's reloaded
+// akvLabelName and poll intervals. The label takes effect on the next sync
+// of every AzureKeyVaultSecret; the poll intervals are stored only (see
+// AzurePollFrequency).
+func (c *Controller) UpdateReloadableConfig(akvLabelName string, normalPollInterval, exceptionPollInterval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.akvLabelName = akvLabelName
+	c.pollFreq.Normal = normalPollInterval
+	c.pollFreq.Slow = exceptionPollInterval
+}
+
+// EnqueueFromVaultEvent implements eventgrid.EnqueueFunc: it looks up
+// every AzureKeyVaultSecret whose vault, object type and object name match
+// vaultBaseURL, objectType and objectName and enqueues each one, so an
+// Event Grid delivery triggers an immediate reconcile instead of waiting
+// for the next poll.
+func (c *Controller) EnqueueFromVaultEvent(vaultBaseURL, objectType, objectName string) {
+	akvsList, err := c.akvsLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("failed to list azurekeyvaultsecrets for event grid delivery, error: %+v", err)
+		return
+	}
+
+	for _, akvs := range akvsList {
+		if !vaultMatches(akvs, vaultBaseURL, objectType, objectName) {
+			continue
+		}
+
+		key, err := cache.MetaNamespaceKeyFunc(akvs)
+		if err != nil {
+			log.Errorf("failed to build workqueue key for %s/%s, error: %+v", akvs.Namespace, akvs.Name, err)
+			continue
+		}
+
+		c.workqueue.Add(key)
+	}
+}
+
+func vaultMatches(akvs *akvv1alpha1.AzureKeyVaultSecret, vaultBaseURL, objectType, objectName string) bool {
+	return fmt.Sprintf("https://%s.vault.azure.net/", akvs.Spec.Vault.Name) == vaultBaseURL &&
+		akvs.Spec.Vault.Object.Type == objectType &&
+		akvs.Spec.Vault.Object.Name == objectName
+}
+
+func (c *Controller) enqueueAzureKeyVaultSecret(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+
+	c.workqueue.Add(key)
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *Controller) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+	if shutdown {
+		return false
+	}
+
+	defer c.workqueue.Done(obj)
+
+	key, ok := obj.(string)
+	if !ok {
+		c.workqueue.Forget(obj)
+		runtime.HandleError(fmt.Errorf("expected string in workqueue but got %#v", obj))
+		return true
+	}
+
+	if err := c.syncHandler(key); err != nil {
+		if c.workqueue.NumRequeues(key) < c.maxNumRequeues {
+			log.Warnf("error syncing '%s', retrying: %s", key, err.Error())
+			c.workqueue.AddRateLimited(key)
+			return true
+		}
+
+		log.Errorf("error syncing '%s', giving up after %d retries: %s", key, c.maxNumRequeues, err.Error())
+		c.workqueue.Forget(obj)
+		return true
+	}
+
+	c.workqueue.Forget(obj)
+	return true
+}
+
+// syncHandler reconciles a single AzureKeyVaultSecret, identified by its
+// namespace/name workqueue key, into the Kubernetes Secret it
+// configures. It always reads the object with vault.Service.GetSecret,
+// whatever spec.vault.object.type says; GetKey and GetCertificate are not
+// used.
+func (c *Controller) syncHandler(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil
+	}
+
+	// A replica only reconciles the AzureKeyVaultSecrets assigned to its
+	// shard - every other replica in the fleet is responsible for the
+	// rest, so skipping here (rather than filtering at the informer/list
+	// level) is what actually splits the reconcile load across shards.
+	if c.shardAssignment != nil && !c.shardAssignment.Owns(namespace, name) {
+		return nil
+	}
+
+	akvs, err := c.akvsLister.AzureKeyVaultSecrets(namespace).Get(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Infof("azurekeyvaultsecret '%s' in work queue no longer exists", key)
+			return nil
+		}
+		return err
+	}
+
+	vaultService := c.vaultService
+	if c.vaultServiceFactory != nil {
+		vaultService, err = c.vaultServiceFactory(vaultAuthOverride(akvs))
+		if err != nil {
+			return fmt.Errorf("failed to resolve vault service for %s, error: %+v", key, err)
+		}
+	}
+
+	ctx := context.Background()
+
+	secretValue, err := vaultService.GetSecret(ctx, vaultBaseURL(akvs), akvs.Spec.Vault.Object.Name, akvs.Spec.Vault.Object.Version)
+	if err != nil {
+		return fmt.Errorf("failed to get secret %s from vault %s, error: %+v", akvs.Spec.Vault.Object.Name, akvs.Spec.Vault.Name, err)
+	}
+
+	return c.upsertSecret(ctx, akvs, *secretValue.Value)
+}
+
+func vaultBaseURL(akvs *akvv1alpha1.AzureKeyVaultSecret) string {
+	return fmt.Sprintf("https://%s.vault.azure.net/", akvs.Spec.Vault.Name)
+}
+
+// vaultAuthOverride translates an AzureKeyVaultSecret's optional
+// spec.vault.auth into the credentialprovider.VaultAuthSelector
+// ResolveVaultAuth expects, so the two packages don't need to depend on
+// each other's CRD/internal types.
+func vaultAuthOverride(akvs *akvv1alpha1.AzureKeyVaultSecret) *credentialprovider.VaultAuthSelector {
+	auth := akvs.Spec.Vault.Auth
+	if auth == nil {
+		return nil
+	}
+
+	selector := &credentialprovider.VaultAuthSelector{}
+
+	if auth.WorkloadIdentity != nil {
+		selector.WorkloadIdentity = &credentialprovider.WorkloadIdentitySelector{
+			ClientID: auth.WorkloadIdentity.ClientID,
+			TenantID: auth.WorkloadIdentity.TenantID,
+		}
+	}
+
+	if auth.SecretRef != nil {
+		selector.SecretRef = &credentialprovider.SecretRefSelector{
+			Name:      auth.SecretRef.Name,
+			Namespace: auth.SecretRef.Namespace,
+		}
+	}
+
+	return selector
+}
+
+func (c *Controller) upsertSecret(ctx context.Context, akvs *akvv1alpha1.AzureKeyVaultSecret, value string) error {
+	c.mu.RLock()
+	labelName := c.akvLabelName
+	c.mu.RUnlock()
+
+	dataKey := akvs.Spec.Output.Secret.DataKey
+	if dataKey == "" {
+		dataKey = "value"
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      akvs.Spec.Output.Secret.Name,
+			Namespace: akvs.Namespace,
+			Labels:    map[string]string{labelName: "true"},
+		},
+		Data: map[string][]byte{
+			dataKey: []byte(value),
+		},
+	}
+
+	secrets := c.kubeclientset.CoreV1().Secrets(akvs.Namespace)
+
+	existing, err := secrets.Get(ctx, secret.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get secret %s/%s, error: %+v", akvs.Namespace, secret.Name, err)
+	}
+
+	secret.ResourceVersion = existing.ResourceVersion
+	_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	return err
+}
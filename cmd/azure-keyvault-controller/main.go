@@ -20,97 +20,93 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/client-go/tools/record"
 
 	"github.com/SparebankenVest/azure-key-vault-to-kubernetes/cmd/azure-keyvault-controller/controller"
 	"github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/akv2k8s"
 	"github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/azure/credentialprovider"
 	vault "github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/azure/keyvault/client"
+	"github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/config"
+	"github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/eventgrid"
 	clientset "github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/k8s/client/clientset/versioned"
 	informers "github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/k8s/client/informers/externalversions"
 	"github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/k8s/signals"
+	"github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/sharding"
+	"github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/status"
 )
 
+const leaseLockName = "akv2k8s-controller"
+
 const controllerAgentName = "azurekeyvaultcontroller"
 
-var (
-	version     string
-	kubeconfig  string
-	masterURL   string
-	cloudconfig string
-)
-
-func initConfig() {
-	viper.SetDefault("version", "dev")
-	viper.SetDefault("log_format", "fmt")
-	viper.SetDefault("akv_label_name", "azure-key-vault-env-injection")
-	viper.SetDefault("ca_config_map_name", "akv2k8s-ca")
-	viper.SetDefault("cloudconfig", "/etc/kubernetes/azure.json")
-	viper.SetDefault("azure_vault_normal_poll_intervals", 1)
-	viper.SetDefault("azure_vault_exception_poll_intervals", 5)
-	viper.SetDefault("azure_vault_max_failure_attempts", 5)
-	viper.SetDefault("custom_auth", false)
-
-	viper.AutomaticEnv()
-}
+var configFile string
 
 func init() {
-	flag.StringVar(&version, "version", "", "Version of this component.")
-	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
-	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
-	flag.StringVar(&cloudconfig, "cloudconfig", "/etc/kubernetes/azure.json", "Path to cloud config. Only required if this is not at default location /etc/kubernetes/azure.json")
+	flag.String("version", "", "Version of this component.")
+	flag.String("kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
+	flag.String("master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	flag.String("cloudconfig", "/etc/kubernetes/azure.json", "Path to cloud config. Only required if this is not at default location /etc/kubernetes/azure.json")
+	flag.String("eventgrid-listen", "", "Address to listen on for Azure Event Grid webhook deliveries, e.g. :8443. Disabled if empty.")
+	flag.Bool("leader-elect", false, "Run multiple controller replicas with only the elected leader active. Can also be set with LEADER_ELECT.")
+	flag.Int("shard-index", 0, "This replica's shard index, in [0, shard-count). Only meaningful when shard-count > 1.")
+	flag.Int("shard-count", 1, "Total number of shards the fleet of controller replicas is split across.")
+	flag.StringVar(&configFile, "config", "", "Path to a YAML config file with any of the above settings. Lower priority than flags and env vars.")
 }
 
 func main() {
 	flag.Parse()
-	initConfig()
-
-	akv2k8s.Version = viper.GetString("version")
 
-	setLogLevel(viper.GetString("log_level"))
-	setLogFormat(viper.GetString("log_format"))
+	cnf, err := config.Load(flag.CommandLine, configFile)
+	if err != nil {
+		log.Fatalf("failed to load config: %s", err.Error())
+	}
 
-	akv2k8s.LogVersion()
+	if err := cnf.Validate(); err != nil {
+		log.Fatalf("invalid config: %s", err.Error())
+	}
 
-	// kubeconfig := viper.GetString("kubeconfig")
-	// masterURL := viper.GetString("master")
-	// cloudconfig := viper.GetString("cloudconfig")
+	akv2k8s.Version = cnf.Version
 
-	azureVaultFastRate := time.Duration(viper.GetInt("azure_vault_normal_poll_intervals")) * time.Minute
-	azureVaultSlowRate := time.Duration(viper.GetInt("azure_vault_exception_poll_intervals")) * time.Minute
-	azureVaultMaxFastAttempts := viper.GetInt("azure_vault_max_failure_attempts")
-	customAuth := viper.GetBool("custom_auth")
+	setLogLevel(cnf.LogLevel)
+	setLogFormat(cnf.LogFormat)
 
-	caConfigMapName := viper.GetString("ca_config_map_name")
-	akvLabelName := viper.GetString("akv_label_name")
-	akvSecretName := viper.GetString("akv_secret_name")
-	akvNamespace := viper.GetString("akv_namespace")
+	akv2k8s.LogVersion()
 
-	if akvSecretName == "" {
-		log.Fatal("Env var AKV_SECRET_NAME required")
+	shardAssignment, err := sharding.NewAssignment(cnf.ShardIndex, cnf.ShardCount)
+	if err != nil {
+		log.Fatalf("invalid shard configuration: %s", err.Error())
 	}
 
-	if akvNamespace == "" {
-		log.Fatal("Env var AKV_NAMESPACE required")
+	if cnf.CustomAuth {
+		log.Warn("custom_auth is deprecated - set auth_type=environment instead")
 	}
 
 	// set up signals so we handle the first shutdown signal gracefully
 	stopCh := signals.SetupSignalHandler()
 
-	cfg, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
+	cfg, err := clientcmd.BuildConfigFromFlags(cnf.MasterURL, cnf.KubeConfig)
 	if err != nil {
 		log.Fatalf("Error building kubeconfig: %s", err.Error())
 	}
@@ -129,9 +125,9 @@ func main() {
 	azureKeyVaultSecretInformerFactory := informers.NewSharedInformerFactory(azureKeyVaultSecretClient, time.Second*30)
 
 	azurePollFrequency := controller.AzurePollFrequency{
-		Normal:                       azureVaultFastRate,
-		Slow:                         azureVaultSlowRate,
-		MaxFailuresBeforeSlowingDown: azureVaultMaxFastAttempts,
+		Normal:                       cnf.NormalPollInterval(),
+		Slow:                         cnf.ExceptionPollInterval(),
+		MaxFailuresBeforeSlowingDown: cnf.AzureVaultMaxFailureAttempts,
 	}
 
 	log.Info("Creating event broadcaster")
@@ -139,31 +135,28 @@ func main() {
 	eventBroadcaster.StartLogging(log.Tracef)
 	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
 
-	var vaultAuth *credentialprovider.AzureKeyVaultCredentials
-	if customAuth {
-		provider, err := credentialprovider.NewFromEnvironment()
-		if err != nil {
-			log.Fatalf("failed to create azure credentials provider, error: %+v", err.Error())
-		}
+	statusReporter := status.NewReporter(status.NewConfigMapWriter(kubeClient, cnf.AkvNamespace, cnf.StatusConfigMap))
 
-		if vaultAuth, err = provider.GetAzureKeyVaultCredentials(); err != nil {
-			log.Fatalf("failed to get azure key vault credentials, error: %+v", err.Error())
-		}
-	} else {
-		f, err := os.Open(cloudconfig)
-		if err != nil {
-			log.Fatalf("Failed reading azure config from %s, error: %+v", cloudconfig, err)
-		}
-		defer f.Close()
+	provider, err := resolveCredentialProvider(cnf, statusReporter)
+	if err != nil {
+		statusReporter.Flush()
+		log.Fatal(err.Error())
+	}
 
-		cloudCnfProvider, err := credentialprovider.NewFromCloudConfig(f)
-		if err != nil {
-			log.Fatalf("Failed reading azure config from %s, error: %+v", cloudconfig, err)
+	var eventGridListener net.Listener
+	if cnf.EventGridListen != "" {
+		l, listenErr := net.Listen("tcp", cnf.EventGridListen)
+		if listenErr == nil {
+			eventGridListener = l
 		}
+		statusReporter.Record(status.StrategyEventGrid, listenErr, time.Now())
+	}
 
-		if vaultAuth, err = cloudCnfProvider.GetAzureKeyVaultCredentials(); err != nil {
-			log.Fatalf("failed to create azure key vault credentials, error: %+v", err.Error())
-		}
+	statusReporter.Flush()
+
+	vaultAuth, err := provider.GetAzureKeyVaultCredentials()
+	if err != nil {
+		log.Fatalf("failed to get azure key vault credentials, error: %+v", err.Error())
 	}
 
 	vaultService := vault.NewService(vaultAuth)
@@ -172,7 +165,13 @@ func main() {
 	options := &controller.Options{
 		MaxNumRequeues:        5,
 		NumThreads:            1,
-		CABundleConfigMapName: caConfigMapName,
+		CABundleConfigMapName: cnf.CAConfigMapName,
+		ShardAssignment:       shardAssignment,
+		// VaultServiceFactory is called once per reconcile with the
+		// AzureKeyVaultSecret's spec.vault.auth (nil for secrets that don't
+		// set an override) and returns the *vault.Service to fetch that
+		// secret's value from - see vaultServiceFactory below.
+		VaultServiceFactory: vaultServiceFactory(kubeClient, provider, vaultService, cnf.AzureFederatedTokenFile),
 	}
 
 	controller := controller.NewController(
@@ -182,13 +181,280 @@ func main() {
 		kubeInformerFactory,
 		recorder,
 		vaultService,
-		akvSecretName,
-		akvNamespace,
-		akvLabelName,
+		cnf.AkvSecretName,
+		cnf.AkvNamespace,
+		cnf.AkvLabelName,
 		azurePollFrequency,
 		options)
 
-	controller.Run(stopCh)
+	kubeInformerFactory.Start(stopCh)
+	azureKeyVaultSecretInformerFactory.Start(stopCh)
+
+	config.WatchForReload(flag.CommandLine, configFile, func(reloaded config.ReloadableFields) {
+		setLogLevel(reloaded.LogLevel)
+		// With Event Grid enabled the normal poll interval keeps its startup
+		// value: azure_vault_normal_poll_intervals_eventgrid is not reloadable.
+		normalPollInterval := time.Duration(reloaded.AzureVaultNormalPollIntervals) * time.Minute
+		if cnf.EventGridListen != "" {
+			normalPollInterval = cnf.NormalPollInterval()
+		}
+		controller.UpdateReloadableConfig(reloaded.AkvLabelName, normalPollInterval, time.Duration(reloaded.AzureVaultExceptionPollIntervals)*time.Minute)
+	}, stopCh)
+
+	if eventGridListener != nil {
+		var verifyAuth func(r *http.Request) error
+		if cnf.EventGridHMACKey != "" {
+			verifyAuth = eventgrid.NewHMACVerifier([]byte(cnf.EventGridHMACKey))
+		}
+
+		receiver := eventgrid.NewReceiver(controller.EnqueueFromVaultEvent, verifyAuth)
+
+		go func() {
+			log.Infof("starting event grid receiver on %s", cnf.EventGridListen)
+			if err := http.Serve(eventGridListener, receiver); err != nil {
+				log.Errorf("event grid receiver stopped: %s", err.Error())
+			}
+		}()
+	}
+
+	if !cnf.LeaderElect {
+		controller.Run(stopCh)
+		return
+	}
+
+	id, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("failed to determine hostname for leader election identity: %s", err.Error())
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      leaseLockName,
+			Namespace: cnf.PodNamespace,
+		},
+		Client: kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: id,
+		},
+	}
+
+	leaderelection.RunOrDie(context.Background(), leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				controller.Run(stopCh)
+			},
+			OnStoppedLeading: func() {
+				log.Info("leadership lost - shutting down so a standby replica can take over")
+				os.Exit(0)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != id {
+					log.Infof("%s is the new leader - keeping informers warm for fast failover", identity)
+				}
+			},
+		},
+	})
+}
+
+// vaultServiceFactory returns the controller's per-reconcile hook for
+// resolving an AzureKeyVaultSecret's optional spec.vault.auth override: it
+// feeds any non-nil override through credentialprovider.ResolveVaultAuth
+// and rebuilds vaultService with the resolved identity's credentials via
+// vault.Service.WithCredentials (an override with neither field set
+// resolves to the controller-wide identity and is rebuilt all the same).
+// Secrets without an override get vaultService back unchanged.
+//
+// The rebuilt *vault.Service (and the azidentity credential behind it) is
+// cached per resolved override identity, since every reconcile of every
+// overridden secret would otherwise re-run the federated-token exchange
+// against AAD - at fleet scale that is enough traffic to get throttled.
+func vaultServiceFactory(kubeClient kubernetes.Interface, controllerWide credentialprovider.CredentialProvider, vaultService *vault.Service, tokenFilePath string) func(override *credentialprovider.VaultAuthSelector) (*vault.Service, error) {
+	var (
+		mu    sync.Mutex
+		cache = map[string]*vault.Service{}
+	)
+
+	return func(override *credentialprovider.VaultAuthSelector) (*vault.Service, error) {
+		if override == nil {
+			return vaultService, nil
+		}
+
+		key := vaultAuthCacheKey(override)
+
+		mu.Lock()
+		if cached, ok := cache[key]; ok {
+			mu.Unlock()
+			return cached, nil
+		}
+		mu.Unlock()
+
+		auth, err := credentialprovider.ResolveVaultAuth(controllerWide, override, tokenFilePath, secretRefLookup(kubeClient))
+		if err != nil {
+			return nil, err
+		}
+
+		creds, err := auth.GetAzureKeyVaultCredentials()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get azure key vault credentials for spec.vault.auth override, error: %+v", err)
+		}
+
+		overridden := vaultService.WithCredentials(creds)
+
+		mu.Lock()
+		cache[key] = overridden
+		mu.Unlock()
+
+		return overridden, nil
+	}
+}
+
+// vaultAuthCacheKey identifies the Azure identity a spec.vault.auth
+// override resolves to, so vaultServiceFactory can cache the
+// *vault.Service built for it instead of rebuilding it on every
+// reconcile of every AzureKeyVaultSecret that sets this same override.
+func vaultAuthCacheKey(override *credentialprovider.VaultAuthSelector) string {
+	switch {
+	case override.WorkloadIdentity != nil:
+		return fmt.Sprintf("workloadIdentity:%s/%s", override.WorkloadIdentity.TenantID, override.WorkloadIdentity.ClientID)
+	case override.SecretRef != nil:
+		return fmt.Sprintf("secretRef:%s/%s", override.SecretRef.Namespace, override.SecretRef.Name)
+	default:
+		return ""
+	}
+}
+
+// secretRefLookup reads the clientId/clientSecret/tenantId keys off the
+// Kubernetes Secret named by a spec.vault.auth.secretRef override.
+func secretRefLookup(kubeClient kubernetes.Interface) func(namespace, name string) (clientID, clientSecret, tenantID string, err error) {
+	return func(namespace, name string) (string, string, string, error) {
+		secret, err := kubeClient.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return "", "", "", fmt.Errorf("failed to get secret %s/%s, error: %+v", namespace, name, err)
+		}
+
+		return string(secret.Data["clientId"]), string(secret.Data["clientSecret"]), string(secret.Data["tenantId"]), nil
+	}
+}
+
+// resolveCredentialProvider picks the azure credentials strategy
+// configured by cnf.AuthType, recording every attempt (and, for "auto",
+// every fallback it had to make) on reporter. It only returns an error
+// once every available strategy has failed.
+func resolveCredentialProvider(cnf *config.ControllerConfig, reporter *status.Reporter) (credentialprovider.CredentialProvider, error) {
+	cloudConfigProvider := func() (credentialprovider.CredentialProvider, error) {
+		f, err := os.Open(cnf.CloudConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading azure config from %s, error: %+v", cnf.CloudConfig, err)
+		}
+		defer f.Close()
+
+		return credentialprovider.NewFromCloudConfig(f)
+	}
+
+	workloadIdentityProvider := func() (credentialprovider.CredentialProvider, error) {
+		return credentialprovider.NewFromWorkloadIdentity(
+			cnf.AzureTenantID,
+			cnf.AzureClientID,
+			cnf.AzureFederatedTokenFile)
+	}
+
+	managedIdentityProvider := func() (credentialprovider.CredentialProvider, error) {
+		return credentialprovider.NewFromManagedIdentity(cnf.AzureClientID)
+	}
+
+	if cnf.AuthType != "auto" {
+		var (
+			provider credentialprovider.CredentialProvider
+			err      error
+			strategy status.StrategyName
+		)
+
+		switch cnf.AuthType {
+		case "workloadIdentity":
+			provider, err = workloadIdentityProvider()
+			strategy = status.StrategyWorkloadIdentity
+		case "environment":
+			provider, err = credentialprovider.NewFromEnvironment()
+			strategy = status.StrategyEnvironmentSPN
+		case "managedIdentity":
+			provider, err = managedIdentityProvider()
+			strategy = status.StrategyManagedIdentity
+		case "default":
+			provider, err = credentialprovider.NewFromDefaultChain()
+			strategy = status.StrategyDefaultChain
+		case "cloudConfig":
+			provider, err = cloudConfigProvider()
+			strategy = status.StrategyCloudConfig
+		}
+
+		if err == nil {
+			err = validateCredential(provider)
+		}
+
+		reporter.Record(strategy, err, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create azure credentials provider, error: %+v", err)
+		}
+
+		return provider, nil
+	}
+
+	// Try every strategy in order, recording each attempt, and use the
+	// first one that works - rather than failing the whole controller
+	// because one identity isn't configured in this cluster.
+	chain := []struct {
+		name     status.StrategyName
+		provider func() (credentialprovider.CredentialProvider, error)
+	}{
+		{status.StrategyWorkloadIdentity, workloadIdentityProvider},
+		{status.StrategyManagedIdentity, managedIdentityProvider},
+		{status.StrategyEnvironmentSPN, credentialprovider.NewFromEnvironment},
+		{status.StrategyCloudConfig, cloudConfigProvider},
+	}
+
+	for _, attempt := range chain {
+		p, err := attempt.provider()
+		if err == nil {
+			err = validateCredential(p)
+		}
+
+		reporter.Record(attempt.name, err, time.Now())
+		if err == nil {
+			return p, nil
+		}
+
+		log.Warnf("auth strategy %s not available: %s", attempt.name, err.Error())
+	}
+
+	return nil, fmt.Errorf("no azure credential strategy succeeded - see controller status for details")
+}
+
+// validateCredential proves a CredentialProvider's azcore.TokenCredential
+// can actually authenticate, by acquiring a token for the Key Vault
+// resource scope. The azidentity constructors almost always succeed
+// regardless of whether the environment can actually authenticate - real
+// failures (missing federated token, AAD app not configured, IMDS
+// unreachable, ...) only surface on the first GetToken call, so that is
+// what status.Reporter needs to see before recording a strategy Success.
+func validateCredential(provider credentialprovider.CredentialProvider) error {
+	creds, err := provider.GetAzureKeyVaultCredentials()
+	if err != nil {
+		return err
+	}
+
+	_, err = creds.Token.GetToken(context.Background(), policy.TokenRequestOptions{
+		Scopes: []string{"https://vault.azure.net/.default"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to acquire an azure ad token for %s, error: %+v", creds.AuthType, err)
+	}
+
+	return nil
 }
 
 func setLogFormat(logFormat string) {
@@ -0,0 +1,45 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentialprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// cloudConfig mirrors the subset of /etc/kubernetes/azure.json that the
+// Azure cloud-provider uses to authenticate, kept here as the last
+// fallback in the auth_type chain.
+type cloudConfig struct {
+	TenantID        string `json:"tenantId"`
+	AADClientID     string `json:"aadClientId"`
+	AADClientSecret string `json:"aadClientSecret"`
+}
+
+func parseCloudConfig(r io.Reader) (*cloudConfig, error) {
+	var cnf cloudConfig
+	if err := json.NewDecoder(r).Decode(&cnf); err != nil {
+		return nil, fmt.Errorf("failed to parse cloud config, error: %+v", err)
+	}
+
+	if cnf.AADClientID == "" || cnf.AADClientSecret == "" || cnf.TenantID == "" {
+		return nil, fmt.Errorf("cloud config is missing one of tenantId, aadClientId or aadClientSecret")
+	}
+
+	return &cnf, nil
+}
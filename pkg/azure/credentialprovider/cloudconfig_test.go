@@ -0,0 +1,51 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentialprovider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCloudConfigValid(t *testing.T) {
+	r := strings.NewReader(`{"tenantId":"tenant","aadClientId":"client","aadClientSecret":"secret"}`)
+
+	cnf, err := parseCloudConfig(r)
+	if err != nil {
+		t.Fatalf("parseCloudConfig failed: %s", err.Error())
+	}
+
+	if cnf.TenantID != "tenant" || cnf.AADClientID != "client" || cnf.AADClientSecret != "secret" {
+		t.Fatalf("unexpected parsed cloud config: %+v", cnf)
+	}
+}
+
+func TestParseCloudConfigMissingField(t *testing.T) {
+	r := strings.NewReader(`{"tenantId":"tenant","aadClientId":"client"}`)
+
+	if _, err := parseCloudConfig(r); err == nil {
+		t.Fatal("expected parseCloudConfig to reject a config missing aadClientSecret")
+	}
+}
+
+func TestParseCloudConfigInvalidJSON(t *testing.T) {
+	r := strings.NewReader(`not json`)
+
+	if _, err := parseCloudConfig(r); err == nil {
+		t.Fatal("expected parseCloudConfig to reject invalid JSON")
+	}
+}
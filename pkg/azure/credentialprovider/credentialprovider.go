@@ -0,0 +1,211 @@
+/*
+Copyright Sparebanken Vest
+
+Based on the Kubernetes controller example at
+https://github.com/kubernetes/sample-controller
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentialprovider
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// AuthType identifies which identity chain a CredentialProvider was built from.
+// It is reported back on AzureKeyVaultCredentials so callers (and, eventually,
+// the controller status object) can tell which strategy is actually in use.
+type AuthType string
+
+const (
+	AuthTypeCloudConfig      AuthType = "CloudConfig"
+	AuthTypeWorkloadIdentity AuthType = "WorkloadIdentity"
+	AuthTypeEnvironmentSPN   AuthType = "EnvironmentSPN"
+	AuthTypeManagedIdentity  AuthType = "ManagedIdentity"
+	AuthTypeDefaultChain     AuthType = "DefaultChain"
+	AuthTypeSecretRef        AuthType = "SecretRef"
+)
+
+// AzureKeyVaultCredentials holds the azcore.TokenCredential used to
+// authenticate against Key Vault, along with the metadata needed to build
+// the track2 data-plane clients (azsecrets, azkeys, azcertificates).
+type AzureKeyVaultCredentials struct {
+	Token    azcore.TokenCredential
+	AuthType AuthType
+}
+
+// CredentialProvider resolves Azure credentials for talking to Key Vault.
+type CredentialProvider interface {
+	GetAzureKeyVaultCredentials() (*AzureKeyVaultCredentials, error)
+}
+
+// provider is the common CredentialProvider implementation shared by every
+// constructor in this package - only the underlying azcore.TokenCredential
+// and its AuthType differ.
+type provider struct {
+	authType AuthType
+	cred     azcore.TokenCredential
+}
+
+func (p *provider) GetAzureKeyVaultCredentials() (*AzureKeyVaultCredentials, error) {
+	return &AzureKeyVaultCredentials{
+		Token:    p.cred,
+		AuthType: p.authType,
+	}, nil
+}
+
+// NewFromDefaultChain builds a CredentialProvider backed by
+// azidentity.NewDefaultAzureCredential, which transparently tries Azure AD
+// Workload Identity (a projected service account token exchanged for a
+// federated credential), managed identity and environment variables, in
+// that order, before giving up. This is the recommended auth_type for
+// clusters that have Workload Identity enabled, since it requires no
+// cluster-specific configuration beyond the standard
+// azure.workload.identity/* pod labels and annotations.
+func NewFromDefaultChain() (CredentialProvider, error) {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create default azure credential chain, error: %+v", err)
+	}
+
+	return &provider{authType: AuthTypeDefaultChain, cred: cred}, nil
+}
+
+// NewFromWorkloadIdentity builds a CredentialProvider explicitly scoped to
+// Azure AD Workload Identity, using the projected service account token at
+// tokenFilePath exchanged for an Azure AD token via a federated identity
+// credential on the given clientID/tenantID app registration. Unlike
+// NewFromDefaultChain the resulting credential never falls back to another
+// identity: it authenticates as clientID/tenantID or fails, which is what
+// the per-secret spec.vault.auth override needs, since it must not silently
+// fall through to a different identity than the one requested for that
+// AzureKeyVaultSecret.
+func NewFromWorkloadIdentity(tenantID, clientID, tokenFilePath string) (CredentialProvider, error) {
+	cred, err := azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+		TenantID:      tenantID,
+		ClientID:      clientID,
+		TokenFilePath: tokenFilePath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workload identity credential, error: %+v", err)
+	}
+
+	return &provider{authType: AuthTypeWorkloadIdentity, cred: cred}, nil
+}
+
+// NewFromEnvironment builds a CredentialProvider from the legacy
+// AZURE_CLIENT_ID / AZURE_CLIENT_SECRET / AZURE_TENANT_ID environment
+// variables, kept for clusters that already provision a service principal
+// this way via --custom-auth.
+func NewFromEnvironment() (CredentialProvider, error) {
+	cred, err := azidentity.NewEnvironmentCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create azure credentials provider from environment, error: %+v", err)
+	}
+
+	return &provider{authType: AuthTypeEnvironmentSPN, cred: cred}, nil
+}
+
+// NewFromManagedIdentity builds a CredentialProvider from the Azure Instance
+// Metadata Service, optionally scoped to a user-assigned identity via
+// clientID.
+func NewFromManagedIdentity(clientID string) (CredentialProvider, error) {
+	opts := &azidentity.ManagedIdentityCredentialOptions{}
+	if clientID != "" {
+		opts.ID = azidentity.ClientID(clientID)
+	}
+
+	cred, err := azidentity.NewManagedIdentityCredential(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create managed identity credential, error: %+v", err)
+	}
+
+	return &provider{authType: AuthTypeManagedIdentity, cred: cred}, nil
+}
+
+// NewFromCloudConfig builds a CredentialProvider from the service principal
+// stored in the Kubernetes cloud-provider config (/etc/kubernetes/azure.json
+// by default). It is kept as the last fallback in the auth_type chain for
+// clusters that have not yet migrated off a cloudconfig-derived SPN.
+func NewFromCloudConfig(cloudConfig io.Reader) (CredentialProvider, error) {
+	cnf, err := parseCloudConfig(cloudConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := azidentity.NewClientSecretCredential(cnf.TenantID, cnf.AADClientID, cnf.AADClientSecret, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client secret credential from cloud config, error: %+v", err)
+	}
+
+	return &provider{authType: AuthTypeCloudConfig, cred: cred}, nil
+}
+
+// VaultAuthSelector is the resolved form of an AzureKeyVaultSecret's
+// optional spec.vault.auth field. When set on a secret it overrides
+// whichever identity the controller would otherwise use, so a single
+// controller deployment can serve namespaces bound to different Azure AD
+// tenants/identities.
+type VaultAuthSelector struct {
+	// WorkloadIdentity, when set, authenticates using the workload identity
+	// federated service account token for ClientID/TenantID.
+	WorkloadIdentity *WorkloadIdentitySelector
+
+	// SecretRef, when set, authenticates using an SPN stored in the
+	// Kubernetes Secret SecretRef.Name in namespace SecretRef.Namespace.
+	SecretRef *SecretRefSelector
+}
+
+type WorkloadIdentitySelector struct {
+	ClientID string
+	TenantID string
+}
+
+type SecretRefSelector struct {
+	Name      string
+	Namespace string
+}
+
+// ResolveVaultAuth returns the CredentialProvider to use for a single
+// AzureKeyVaultSecret, given the controller-wide default and that secret's
+// optional spec.vault.auth override. Secrets that do not set an override
+// keep using the controller-wide identity.
+func ResolveVaultAuth(controllerWide CredentialProvider, override *VaultAuthSelector, tokenFilePath string, secretLookup func(namespace, name string) (clientID, clientSecret, tenantID string, err error)) (CredentialProvider, error) {
+	if override == nil {
+		return controllerWide, nil
+	}
+
+	switch {
+	case override.WorkloadIdentity != nil:
+		return NewFromWorkloadIdentity(override.WorkloadIdentity.TenantID, override.WorkloadIdentity.ClientID, tokenFilePath)
+	case override.SecretRef != nil:
+		clientID, clientSecret, tenantID, err := secretLookup(override.SecretRef.Namespace, override.SecretRef.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve vault auth secretRef %s/%s, error: %+v", override.SecretRef.Namespace, override.SecretRef.Name, err)
+		}
+
+		cred, err := azidentity.NewClientSecretCredential(tenantID, clientID, clientSecret, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client secret credential from secretRef, error: %+v", err)
+		}
+
+		return &provider{authType: AuthTypeSecretRef, cred: cred}, nil
+	default:
+		return controllerWide, nil
+	}
+}
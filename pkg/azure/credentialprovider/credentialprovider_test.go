@@ -0,0 +1,95 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentialprovider
+
+import (
+	"fmt"
+	"testing"
+)
+
+type stubProvider struct {
+	authType AuthType
+}
+
+func (s stubProvider) GetAzureKeyVaultCredentials() (*AzureKeyVaultCredentials, error) {
+	return &AzureKeyVaultCredentials{AuthType: s.authType}, nil
+}
+
+func TestResolveVaultAuthWithNoOverrideReturnsControllerWide(t *testing.T) {
+	controllerWide := stubProvider{authType: AuthTypeDefaultChain}
+
+	got, err := ResolveVaultAuth(controllerWide, nil, "", nil)
+	if err != nil {
+		t.Fatalf("ResolveVaultAuth failed: %s", err.Error())
+	}
+
+	if got != controllerWide {
+		t.Fatalf("expected ResolveVaultAuth to return controllerWide unchanged")
+	}
+}
+
+func TestResolveVaultAuthWithEmptySelectorReturnsControllerWide(t *testing.T) {
+	controllerWide := stubProvider{authType: AuthTypeDefaultChain}
+
+	got, err := ResolveVaultAuth(controllerWide, &VaultAuthSelector{}, "", nil)
+	if err != nil {
+		t.Fatalf("ResolveVaultAuth failed: %s", err.Error())
+	}
+
+	if got != controllerWide {
+		t.Fatalf("expected ResolveVaultAuth to fall back to controllerWide for an override with neither field set")
+	}
+}
+
+func TestResolveVaultAuthWithSecretRefUsesLookupResult(t *testing.T) {
+	controllerWide := stubProvider{authType: AuthTypeDefaultChain}
+	override := &VaultAuthSelector{SecretRef: &SecretRefSelector{Name: "vault-creds", Namespace: "team-a"}}
+
+	lookup := func(namespace, name string) (string, string, string, error) {
+		if namespace != "team-a" || name != "vault-creds" {
+			t.Fatalf("unexpected secretLookup args: %s/%s", namespace, name)
+		}
+		return "client-id", "client-secret", "tenant-id", nil
+	}
+
+	got, err := ResolveVaultAuth(controllerWide, override, "", lookup)
+	if err != nil {
+		t.Fatalf("ResolveVaultAuth failed: %s", err.Error())
+	}
+
+	creds, err := got.GetAzureKeyVaultCredentials()
+	if err != nil {
+		t.Fatalf("GetAzureKeyVaultCredentials failed: %s", err.Error())
+	}
+
+	if creds.AuthType != AuthTypeSecretRef {
+		t.Fatalf("expected AuthTypeSecretRef, got %s", creds.AuthType)
+	}
+}
+
+func TestResolveVaultAuthWithSecretRefPropagatesLookupError(t *testing.T) {
+	controllerWide := stubProvider{authType: AuthTypeDefaultChain}
+	override := &VaultAuthSelector{SecretRef: &SecretRefSelector{Name: "vault-creds", Namespace: "team-a"}}
+
+	lookup := func(namespace, name string) (string, string, string, error) {
+		return "", "", "", fmt.Errorf("secret not found")
+	}
+
+	if _, err := ResolveVaultAuth(controllerWide, override, "", lookup); err == nil {
+		t.Fatal("expected ResolveVaultAuth to propagate the secretLookup error")
+	}
+}
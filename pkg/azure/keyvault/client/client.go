@@ -0,0 +1,140 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package client talks to Azure Key Vault using the track2
+// azsecrets/azkeys/azcertificates SDKs, authenticated through an
+// azcore.TokenCredential obtained from credentialprovider.
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azcertificates"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+
+	"github.com/SparebankenVest/azure-key-vault-to-kubernetes/pkg/azure/credentialprovider"
+)
+
+// Service fetches secrets, keys and certificates from any number of Azure
+// Key Vaults, creating its data-plane clients lazily per vaultBaseURL since a
+// controller can watch AzureKeyVaultSecrets pointing at many different
+// vaults.
+type Service struct {
+	credentials *credentialprovider.AzureKeyVaultCredentials
+	secrets     map[string]*azsecrets.Client
+	keys        map[string]*azkeys.Client
+	certs       map[string]*azcertificates.Client
+}
+
+// NewService creates a vault Service backed by the given credentials. The
+// credentials' azcore.TokenCredential is shared across every vault this
+// Service is asked to talk to.
+func NewService(credentials *credentialprovider.AzureKeyVaultCredentials) *Service {
+	return &Service{
+		credentials: credentials,
+		secrets:     map[string]*azsecrets.Client{},
+		keys:        map[string]*azkeys.Client{},
+		certs:       map[string]*azcertificates.Client{},
+	}
+}
+
+func (s *Service) secretsClient(vaultBaseURL string) (*azsecrets.Client, error) {
+	if c, ok := s.secrets[vaultBaseURL]; ok {
+		return c, nil
+	}
+
+	c, err := azsecrets.NewClient(vaultBaseURL, s.credentials.Token, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secrets client for vault %s, error: %+v", vaultBaseURL, err)
+	}
+
+	s.secrets[vaultBaseURL] = c
+	return c, nil
+}
+
+func (s *Service) keysClient(vaultBaseURL string) (*azkeys.Client, error) {
+	if c, ok := s.keys[vaultBaseURL]; ok {
+		return c, nil
+	}
+
+	c, err := azkeys.NewClient(vaultBaseURL, s.credentials.Token, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create keys client for vault %s, error: %+v", vaultBaseURL, err)
+	}
+
+	s.keys[vaultBaseURL] = c
+	return c, nil
+}
+
+func (s *Service) certificatesClient(vaultBaseURL string) (*azcertificates.Client, error) {
+	if c, ok := s.certs[vaultBaseURL]; ok {
+		return c, nil
+	}
+
+	c, err := azcertificates.NewClient(vaultBaseURL, s.credentials.Token, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificates client for vault %s, error: %+v", vaultBaseURL, err)
+	}
+
+	s.certs[vaultBaseURL] = c
+	return c, nil
+}
+
+// GetSecret returns the current value of a Key Vault secret. ctx bounds
+// the request so a caller (e.g. the controller shutting down) can cancel
+// a hung call instead of blocking a worker indefinitely.
+func (s *Service) GetSecret(ctx context.Context, vaultBaseURL, name, version string) (azsecrets.GetSecretResponse, error) {
+	c, err := s.secretsClient(vaultBaseURL)
+	if err != nil {
+		return azsecrets.GetSecretResponse{}, err
+	}
+
+	return c.GetSecret(ctx, name, version, nil)
+}
+
+// GetKey returns the current value of a Key Vault key. ctx bounds the
+// request so a caller (e.g. the controller shutting down) can cancel a
+// hung call instead of blocking a worker indefinitely.
+func (s *Service) GetKey(ctx context.Context, vaultBaseURL, name, version string) (azkeys.GetKeyResponse, error) {
+	c, err := s.keysClient(vaultBaseURL)
+	if err != nil {
+		return azkeys.GetKeyResponse{}, err
+	}
+
+	return c.GetKey(ctx, name, version, nil)
+}
+
+// GetCertificate returns the current value of a Key Vault certificate.
+// ctx bounds the request so a caller (e.g. the controller shutting down)
+// can cancel a hung call instead of blocking a worker indefinitely.
+func (s *Service) GetCertificate(ctx context.Context, vaultBaseURL, name, version string) (azcertificates.GetCertificateResponse, error) {
+	c, err := s.certificatesClient(vaultBaseURL)
+	if err != nil {
+		return azcertificates.GetCertificateResponse{}, err
+	}
+
+	return c.GetCertificate(ctx, name, version, nil)
+}
+
+// WithCredentials rebuilds this Service using a different credential, used
+// when an AzureKeyVaultSecret's spec.vault.auth override resolves to an
+// identity other than the controller-wide default. The existing per-vault
+// client caches are dropped since they were bound to the old credential.
+func (s *Service) WithCredentials(credentials *credentialprovider.AzureKeyVaultCredentials) *Service {
+	return NewService(credentials)
+}
@@ -0,0 +1,254 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config is the flag/env/YAML configuration surface for the
+// azure-keyvault-controller binary, whose ControllerConfig is the only
+// config type it defines - modeled on the dedicated pkg/flags split in
+// ingress-gce. Centralizing it here means the startup
+// path can be unit tested (Validate returns aggregated errors instead of
+// calling log.Fatal) and a subset of fields can be hot-reloaded without a
+// restart.
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/viper"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// ControllerConfig is the fully resolved configuration for the
+// azure-keyvault-controller binary. Fields are populated, in increasing
+// priority, from defaults, an optional YAML file, environment variables
+// and command-line flags.
+// ControllerConfig's json tags are deliberately the same snake_case names
+// used for its environment variables and viper keys (e.g. "log_level",
+// "akv_secret_name"), so a YAML config file can use exactly those names -
+// there is only one naming convention to learn across flags, env vars and
+// the config file.
+type ControllerConfig struct {
+	Version    string `json:"version,omitempty"`
+	KubeConfig string `json:"kubeconfig,omitempty"`
+	MasterURL  string `json:"master,omitempty"`
+
+	LogLevel  string `json:"log_level,omitempty"`
+	LogFormat string `json:"log_format,omitempty"`
+
+	CloudConfig string `json:"cloudconfig,omitempty"`
+	AuthType    string `json:"auth_type,omitempty"`
+	CustomAuth  bool   `json:"custom_auth,omitempty"`
+
+	AkvLabelName    string `json:"akv_label_name,omitempty"`
+	CAConfigMapName string `json:"ca_config_map_name,omitempty"`
+	AkvSecretName   string `json:"akv_secret_name,omitempty"`
+	AkvNamespace    string `json:"akv_namespace,omitempty"`
+	PodNamespace    string `json:"pod_namespace,omitempty"`
+	StatusConfigMap string `json:"status_config_map_name,omitempty"`
+
+	AzureVaultNormalPollIntervals          int `json:"azure_vault_normal_poll_intervals,omitempty"`
+	AzureVaultNormalPollIntervalsEventGrid int `json:"azure_vault_normal_poll_intervals_eventgrid,omitempty"`
+	AzureVaultExceptionPollIntervals       int `json:"azure_vault_exception_poll_intervals,omitempty"`
+	AzureVaultMaxFailureAttempts           int `json:"azure_vault_max_failure_attempts,omitempty"`
+
+	EventGridListen  string `json:"eventgrid_listen,omitempty"`
+	EventGridHMACKey string `json:"eventgrid_hmac_key,omitempty"`
+
+	LeaderElect bool `json:"leader_elect,omitempty"`
+	ShardIndex  int  `json:"shard_index,omitempty"`
+	ShardCount  int  `json:"shard_count,omitempty"`
+
+	AzureTenantID           string `json:"azure_tenant_id,omitempty"`
+	AzureClientID           string `json:"azure_client_id,omitempty"`
+	AzureFederatedTokenFile string `json:"azure_federated_token_file,omitempty"`
+}
+
+// NormalPollInterval returns the poll interval to use for the fast/normal
+// rate, accounting for the much longer default that applies once the
+// Event Grid webhook path is handling reconciles.
+func (c *ControllerConfig) NormalPollInterval() time.Duration {
+	if c.EventGridListen != "" {
+		return time.Duration(c.AzureVaultNormalPollIntervalsEventGrid) * time.Minute
+	}
+
+	return time.Duration(c.AzureVaultNormalPollIntervals) * time.Minute
+}
+
+// ExceptionPollInterval returns the poll interval to use once a vault has
+// started failing requests.
+func (c *ControllerConfig) ExceptionPollInterval() time.Duration {
+	return time.Duration(c.AzureVaultExceptionPollIntervals) * time.Minute
+}
+
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("version", "dev")
+	v.SetDefault("log_format", "fmt")
+	v.SetDefault("akv_label_name", "azure-key-vault-env-injection")
+	v.SetDefault("ca_config_map_name", "akv2k8s-ca")
+	v.SetDefault("cloudconfig", "/etc/kubernetes/azure.json")
+	v.SetDefault("azure_vault_normal_poll_intervals", 1)
+	v.SetDefault("azure_vault_normal_poll_intervals_eventgrid", 60)
+	v.SetDefault("azure_vault_exception_poll_intervals", 5)
+	v.SetDefault("azure_vault_max_failure_attempts", 5)
+	v.SetDefault("custom_auth", false)
+	v.SetDefault("auth_type", "cloudConfig")
+	v.SetDefault("eventgrid_listen", "")
+	v.SetDefault("leader_elect", false)
+	v.SetDefault("shard_index", 0)
+	v.SetDefault("shard_count", 1)
+	v.SetDefault("pod_namespace", "default")
+	v.SetDefault("status_config_map_name", "akv2k8s-controller-status")
+}
+
+// Load builds a ControllerConfig from, in increasing priority: built-in
+// defaults, the YAML file at configFile (if non-empty), environment
+// variables, and the already-parsed flags in fs.
+func Load(fs *flag.FlagSet, configFile string) (*ControllerConfig, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	if configFile != "" {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s, error: %+v", configFile, err)
+		}
+
+		asJSON, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s, error: %+v", configFile, err)
+		}
+
+		v.SetConfigType("json")
+		if err := v.MergeConfig(bytes.NewReader(asJSON)); err != nil {
+			return nil, fmt.Errorf("failed to load config file %s, error: %+v", configFile, err)
+		}
+	}
+
+	v.AutomaticEnv()
+
+	cnf := &ControllerConfig{
+		Version:                                v.GetString("version"),
+		KubeConfig:                             v.GetString("kubeconfig"),
+		MasterURL:                              v.GetString("master"),
+		LogLevel:                               v.GetString("log_level"),
+		LogFormat:                              v.GetString("log_format"),
+		CloudConfig:                            v.GetString("cloudconfig"),
+		AuthType:                               v.GetString("auth_type"),
+		CustomAuth:                             v.GetBool("custom_auth"),
+		AkvLabelName:                           v.GetString("akv_label_name"),
+		CAConfigMapName:                        v.GetString("ca_config_map_name"),
+		AkvSecretName:                          v.GetString("akv_secret_name"),
+		AkvNamespace:                           v.GetString("akv_namespace"),
+		PodNamespace:                           v.GetString("pod_namespace"),
+		StatusConfigMap:                        v.GetString("status_config_map_name"),
+		AzureVaultNormalPollIntervals:          v.GetInt("azure_vault_normal_poll_intervals"),
+		AzureVaultNormalPollIntervalsEventGrid: v.GetInt("azure_vault_normal_poll_intervals_eventgrid"),
+		AzureVaultExceptionPollIntervals:       v.GetInt("azure_vault_exception_poll_intervals"),
+		AzureVaultMaxFailureAttempts:           v.GetInt("azure_vault_max_failure_attempts"),
+		EventGridListen:                        v.GetString("eventgrid_listen"),
+		EventGridHMACKey:                       v.GetString("eventgrid_hmac_key"),
+		LeaderElect:                            v.GetBool("leader_elect"),
+		ShardIndex:                             v.GetInt("shard_index"),
+		ShardCount:                             v.GetInt("shard_count"),
+		AzureTenantID:                          v.GetString("azure_tenant_id"),
+		AzureClientID:                          v.GetString("azure_client_id"),
+		AzureFederatedTokenFile:                v.GetString("azure_federated_token_file"),
+	}
+
+	applyFlags(fs, cnf)
+
+	if cnf.CustomAuth {
+		cnf.AuthType = "environment"
+	}
+
+	return cnf, nil
+}
+
+// applyFlags overrides config fields with any flags the user explicitly
+// set on the command line, so flags remain the highest-priority source.
+func applyFlags(fs *flag.FlagSet, cnf *ControllerConfig) {
+	if fs == nil {
+		return
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "kubeconfig":
+			cnf.KubeConfig = f.Value.String()
+		case "master":
+			cnf.MasterURL = f.Value.String()
+		case "cloudconfig":
+			cnf.CloudConfig = f.Value.String()
+		case "version":
+			cnf.Version = f.Value.String()
+		case "eventgrid-listen":
+			cnf.EventGridListen = f.Value.String()
+		case "leader-elect":
+			cnf.LeaderElect = f.Value.String() == "true"
+		case "shard-index":
+			fmt.Sscanf(f.Value.String(), "%d", &cnf.ShardIndex)
+		case "shard-count":
+			fmt.Sscanf(f.Value.String(), "%d", &cnf.ShardCount)
+		}
+	})
+}
+
+// Validate checks that every required field is set and that auth_type,
+// shard_index/shard_count and the normal and exception poll intervals are
+// valid, returning every problem found at once instead of stopping at the
+// first one. azure_vault_normal_poll_intervals_eventgrid and
+// azure_vault_max_failure_attempts are not checked.
+func (c *ControllerConfig) Validate() error {
+	var errs []error
+
+	if c.AkvSecretName == "" {
+		errs = append(errs, fmt.Errorf("akv_secret_name is required"))
+	}
+
+	if c.AkvNamespace == "" {
+		errs = append(errs, fmt.Errorf("akv_namespace is required"))
+	}
+
+	switch c.AuthType {
+	case "auto", "cloudConfig", "workloadIdentity", "environment", "managedIdentity", "default":
+	default:
+		errs = append(errs, fmt.Errorf("auth_type %q is not one of auto, cloudConfig, workloadIdentity, environment, managedIdentity, default", c.AuthType))
+	}
+
+	if c.ShardCount < 1 {
+		errs = append(errs, fmt.Errorf("shard_count must be at least 1, got %d", c.ShardCount))
+	} else if c.ShardIndex < 0 || c.ShardIndex >= c.ShardCount {
+		errs = append(errs, fmt.Errorf("shard_index %d out of range for shard_count %d", c.ShardIndex, c.ShardCount))
+	}
+
+	if c.LeaderElect && c.ShardCount > 1 {
+		errs = append(errs, fmt.Errorf("leader_elect and shard_count > 1 are mutually exclusive: leader election runs a single active replica, which would leave every shard but its own unreconciled"))
+	}
+
+	if c.AzureVaultNormalPollIntervals < 1 {
+		errs = append(errs, fmt.Errorf("azure_vault_normal_poll_intervals must be at least 1"))
+	}
+
+	if c.AzureVaultExceptionPollIntervals < 1 {
+		errs = append(errs, fmt.Errorf("azure_vault_exception_poll_intervals must be at least 1"))
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
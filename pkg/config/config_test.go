@@ -0,0 +1,168 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("version", "", "")
+	fs.String("kubeconfig", "", "")
+	fs.String("master", "", "")
+	fs.String("cloudconfig", "/etc/kubernetes/azure.json", "")
+	fs.String("eventgrid-listen", "", "")
+	fs.Bool("leader-elect", false, "")
+	fs.Int("shard-index", 0, "")
+	fs.Int("shard-count", 1, "")
+	fs.String("config", "", "")
+	return fs
+}
+
+func TestLoadAppliesDefaults(t *testing.T) {
+	cnf, err := Load(newFlagSet(), "")
+	if err != nil {
+		t.Fatalf("Load failed: %s", err.Error())
+	}
+
+	if cnf.AuthType != "cloudConfig" {
+		t.Fatalf("expected default auth_type cloudConfig, got %q", cnf.AuthType)
+	}
+
+	if cnf.ShardCount != 1 {
+		t.Fatalf("expected default shard_count 1, got %d", cnf.ShardCount)
+	}
+
+	if cnf.AzureVaultNormalPollIntervals != 1 {
+		t.Fatalf("expected default azure_vault_normal_poll_intervals 1, got %d", cnf.AzureVaultNormalPollIntervals)
+	}
+}
+
+func TestLoadConfigFileOverridesDefaults(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("auth_type: workloadIdentity\nshard_count: 3\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %s", err.Error())
+	}
+
+	cnf, err := Load(newFlagSet(), configFile)
+	if err != nil {
+		t.Fatalf("Load failed: %s", err.Error())
+	}
+
+	if cnf.AuthType != "workloadIdentity" {
+		t.Fatalf("expected auth_type from config file, got %q", cnf.AuthType)
+	}
+
+	if cnf.ShardCount != 3 {
+		t.Fatalf("expected shard_count from config file, got %d", cnf.ShardCount)
+	}
+}
+
+func TestLoadEnvOverridesConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configFile, []byte("auth_type: workloadIdentity\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %s", err.Error())
+	}
+
+	t.Setenv("AUTH_TYPE", "managedIdentity")
+
+	cnf, err := Load(newFlagSet(), configFile)
+	if err != nil {
+		t.Fatalf("Load failed: %s", err.Error())
+	}
+
+	if cnf.AuthType != "managedIdentity" {
+		t.Fatalf("expected auth_type from env var to win over config file, got %q", cnf.AuthType)
+	}
+}
+
+func TestLoadFlagOverridesEnv(t *testing.T) {
+	t.Setenv("SHARD_COUNT", "2")
+
+	fs := newFlagSet()
+	if err := fs.Parse([]string{"--shard-count=5"}); err != nil {
+		t.Fatalf("failed to parse flags: %s", err.Error())
+	}
+
+	cnf, err := Load(fs, "")
+	if err != nil {
+		t.Fatalf("Load failed: %s", err.Error())
+	}
+
+	if cnf.ShardCount != 5 {
+		t.Fatalf("expected flag to win over env var, got shard_count %d", cnf.ShardCount)
+	}
+}
+
+func TestValidateAggregatesErrors(t *testing.T) {
+	cnf := &ControllerConfig{
+		AuthType:                         "not-a-real-type",
+		ShardCount:                       1,
+		AzureVaultNormalPollIntervals:    1,
+		AzureVaultExceptionPollIntervals: 1,
+	}
+
+	err := cnf.Validate()
+	if err == nil {
+		t.Fatal("expected Validate to return an error")
+	}
+
+	msg := err.Error()
+	for _, want := range []string{"akv_secret_name is required", "akv_namespace is required", "auth_type"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("expected error message to contain %q, got %q", want, msg)
+		}
+	}
+}
+
+func TestValidateRejectsLeaderElectWithSharding(t *testing.T) {
+	cnf := &ControllerConfig{
+		AkvSecretName:                    "akv-secret",
+		AkvNamespace:                     "default",
+		AuthType:                         "cloudConfig",
+		LeaderElect:                      true,
+		ShardCount:                       2,
+		AzureVaultNormalPollIntervals:    1,
+		AzureVaultExceptionPollIntervals: 1,
+	}
+
+	if err := cnf.Validate(); err == nil {
+		t.Fatal("expected Validate to reject leader_elect with shard_count > 1")
+	}
+}
+
+func TestValidateAcceptsWellFormedConfig(t *testing.T) {
+	cnf := &ControllerConfig{
+		AkvSecretName:                    "akv-secret",
+		AkvNamespace:                     "default",
+		AuthType:                         "cloudConfig",
+		ShardCount:                       1,
+		AzureVaultNormalPollIntervals:    1,
+		AzureVaultExceptionPollIntervals: 1,
+	}
+
+	if err := cnf.Validate(); err != nil {
+		t.Fatalf("expected well-formed config to be valid, got error: %s", err.Error())
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReloadableFields is the subset of ControllerConfig that is safe to
+// change without restarting the process: it only affects logging
+// verbosity, how often vaults are polled, and the label the controller
+// puts on the Secrets it writes - never
+// anything that would change which Azure identity or Kubernetes
+// permissions the controller runs with.
+type ReloadableFields struct {
+	LogLevel                         string
+	AkvLabelName                     string
+	AzureVaultNormalPollIntervals    int
+	AzureVaultExceptionPollIntervals int
+}
+
+func (c *ControllerConfig) reloadable() ReloadableFields {
+	return ReloadableFields{
+		LogLevel:                         c.LogLevel,
+		AkvLabelName:                     c.AkvLabelName,
+		AzureVaultNormalPollIntervals:    c.AzureVaultNormalPollIntervals,
+		AzureVaultExceptionPollIntervals: c.AzureVaultExceptionPollIntervals,
+	}
+}
+
+// WatchForReload calls onChange with the reloaded ReloadableFields every
+// time the process receives SIGHUP, or the config file at configFile (if
+// non-empty) is written to. It runs until stopCh is closed. Errors
+// reloading the file are logged and otherwise ignored - the previous
+// config keeps applying.
+func WatchForReload(fs *flag.FlagSet, configFile string, onChange func(ReloadableFields), stopCh <-chan struct{}) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	var watcher *fsnotify.Watcher
+	if configFile != "" {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			log.Warnf("failed to watch config file %s for changes: %s", configFile, err.Error())
+		} else {
+			// Watch the containing directory rather than the file itself:
+			// ConfigMap volumes (and many editors) update their target by
+			// atomically repointing a symlink to a new file, which replaces
+			// the watched inode and would silently stop a direct file
+			// watch from ever firing again. The directory inode is stable
+			// across that swap.
+			if err := w.Add(filepath.Dir(configFile)); err != nil {
+				log.Warnf("failed to watch config file %s for changes: %s", configFile, err.Error())
+			} else {
+				watcher = w
+			}
+		}
+	}
+
+	reload := func(source string) {
+		cnf, err := Load(fs, configFile)
+		if err != nil {
+			log.Errorf("failed to reload config after %s, keeping previous config: %s", source, err.Error())
+			return
+		}
+
+		if err := cnf.Validate(); err != nil {
+			log.Errorf("reloaded config after %s is invalid, keeping previous config: %s", source, err.Error())
+			return
+		}
+
+		log.Infof("reloaded config after %s", source)
+		onChange(cnf.reloadable())
+	}
+
+	go func() {
+		defer func() {
+			if watcher != nil {
+				watcher.Close()
+			}
+		}()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-sighup:
+				reload("SIGHUP")
+			case event, ok := <-watcherEvents(watcher):
+				if !ok {
+					continue
+				}
+				if configFileChanged(event, configFile) {
+					reload("config file change")
+				}
+			}
+		}
+	}()
+}
+
+// watcherEvents returns w.Events, or a nil channel (which blocks forever
+// in a select) if w is nil - so WatchForReload can select on it
+// unconditionally whether or not a config file is being watched.
+func watcherEvents(w *fsnotify.Watcher) chan fsnotify.Event {
+	if w == nil {
+		return nil
+	}
+
+	return w.Events
+}
+
+// configFileChanged reports whether a directory event is relevant to
+// configFile. It also matches "..data", the symlink a mounted ConfigMap
+// volume repoints on every update, since the directory event for the
+// actual config file name alone is not guaranteed to fire through that
+// indirection.
+func configFileChanged(event fsnotify.Event, configFile string) bool {
+	if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+		return false
+	}
+
+	base := filepath.Base(event.Name)
+	return base == filepath.Base(configFile) || base == "..data"
+}
@@ -0,0 +1,67 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventgrid
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func sign(key []byte, body string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHMACVerifierAcceptsValidSignature(t *testing.T) {
+	key := []byte("shared-secret")
+	body := `[{"id":"1"}]`
+
+	verify := NewHMACVerifier(key)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Aeg-Delivery-Signature", sign(key, body))
+
+	if err := verify(req); err != nil {
+		t.Fatalf("expected a validly signed request to be accepted, got error: %s", err.Error())
+	}
+}
+
+func TestHMACVerifierRejectsMissingSignature(t *testing.T) {
+	verify := NewHMACVerifier([]byte("shared-secret"))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`[]`))
+
+	if err := verify(req); err == nil {
+		t.Fatal("expected a request with no Aeg-Delivery-Signature header to be rejected")
+	}
+}
+
+func TestHMACVerifierRejectsWrongSignature(t *testing.T) {
+	verify := NewHMACVerifier([]byte("shared-secret"))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`[{"id":"1"}]`))
+	req.Header.Set("Aeg-Delivery-Signature", sign([]byte("wrong-secret"), `[{"id":"1"}]`))
+
+	if err := verify(req); err == nil {
+		t.Fatal("expected a request signed with the wrong key to be rejected")
+	}
+}
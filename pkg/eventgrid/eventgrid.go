@@ -0,0 +1,220 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package eventgrid receives Azure Event Grid webhook deliveries for Key
+// Vault events (SecretNewVersionCreated, SecretExpired,
+// CertificateNewVersionCreated, KeyNewVersionCreated) and turns them into
+// controller workqueue keys, so changed AzureKeyVaultSecrets are
+// reconciled immediately instead of waiting for the next poll.
+package eventgrid
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	eventsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "akv2k8s_eventgrid_events_received_total",
+		Help: "Number of Event Grid events received by event type.",
+	}, []string{"event_type"})
+
+	reconcilesTriggered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "akv2k8s_eventgrid_reconciles_triggered_total",
+		Help: "Number of controller reconciles enqueued from Event Grid events.",
+	}, []string{"event_type"})
+)
+
+// keyVaultEventTypes are the Microsoft.KeyVault event types the receiver
+// understands. Anything else is acknowledged but ignored.
+var keyVaultEventTypes = map[string]bool{
+	"Microsoft.KeyVault.SecretNewVersionCreated":      true,
+	"Microsoft.KeyVault.SecretExpired":                true,
+	"Microsoft.KeyVault.CertificateNewVersionCreated": true,
+	"Microsoft.KeyVault.KeyNewVersionCreated":         true,
+}
+
+// EnqueueFunc enqueues the AzureKeyVaultSecrets backed by the given Key
+// Vault object into the controller's workqueue for immediate reconcile.
+type EnqueueFunc func(vaultBaseURL, objectType, objectName string)
+
+// cloudEvent is the subset of a CloudEvents 1.0 envelope that Event Grid's
+// CloudEvents schema delivers.
+type cloudEvent struct {
+	ID          string        `json:"id"`
+	Source      string        `json:"source"`
+	Type        string        `json:"type"`
+	Data        keyVaultEvent `json:"data"`
+	SpecVersion string        `json:"specversion"`
+}
+
+// eventGridSchemaEvent is the subset of Event Grid's own (non-CloudEvents)
+// schema, used for the subscription validation handshake.
+type eventGridSchemaEvent struct {
+	ID        string          `json:"id"`
+	EventType string          `json:"eventType"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type subscriptionValidationData struct {
+	ValidationCode string `json:"validationCode"`
+}
+
+type subscriptionValidationResponse struct {
+	ValidationResponse string `json:"validationResponse"`
+}
+
+type keyVaultEvent struct {
+	VaultName  string `json:"VaultName"`
+	ObjectType string `json:"ObjectType"`
+	ObjectName string `json:"ObjectName"`
+	Id         string `json:"Id"`
+}
+
+// Receiver is an http.Handler that accepts Event Grid webhook deliveries.
+type Receiver struct {
+	enqueue    EnqueueFunc
+	verifyAuth func(r *http.Request) error
+}
+
+// NewReceiver creates a Receiver that calls enqueue for every recognised
+// Key Vault event, after verifyAuth has authenticated the request (HMAC
+// signature or Azure AD token, depending on how the Event Grid
+// subscription was configured). A nil verifyAuth accepts every request,
+// which is only safe behind a network boundary that already restricts
+// who can reach --eventgrid-listen.
+func NewReceiver(enqueue EnqueueFunc, verifyAuth func(r *http.Request) error) *Receiver {
+	return &Receiver{enqueue: enqueue, verifyAuth: verifyAuth}
+}
+
+func (rv *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		rv.handleAbuseProtectionHandshake(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if rv.verifyAuth != nil {
+		if err := rv.verifyAuth(r); err != nil {
+			log.Warnf("rejected event grid delivery: %s", err.Error())
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if aeg := r.Header.Get("Aeg-Event-Type"); aeg == "SubscriptionValidation" {
+		rv.handleValidation(w, r)
+		return
+	}
+
+	var events []cloudEvent
+	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+		log.Warnf("failed to decode event grid payload: %s", err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	for _, event := range events {
+		rv.handleEvent(event)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleValidation answers the subscription validation handshake used when
+// an Event Grid subscription is configured with the classic Event Grid
+// schema rather than CloudEvents. It is kept alongside
+// handleAbuseProtectionHandshake so this receiver validates correctly
+// regardless of which schema the subscription ends up using.
+func (rv *Receiver) handleValidation(w http.ResponseWriter, r *http.Request) {
+	var events []eventGridSchemaEvent
+	if err := json.NewDecoder(r.Body).Decode(&events); err != nil || len(events) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var validation subscriptionValidationData
+	if err := json.Unmarshal(events[0].Data, &validation); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	log.Info("validating event grid subscription handshake")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriptionValidationResponse{ValidationResponse: validation.ValidationCode})
+}
+
+// handleAbuseProtectionHandshake answers the CloudEvents schema's abuse
+// protection validation handshake: before Event Grid starts delivering
+// events to a new webhook endpoint, it sends an OPTIONS request carrying a
+// WebHook-Request-Origin header and expects the response to echo it back
+// as WebHook-Allowed-Origin. Without this, a subscription configured with
+// the CloudEvents schema - which is what this receiver decodes real
+// deliveries as - never leaves its "validation pending" state and no
+// events are ever delivered.
+func (rv *Receiver) handleAbuseProtectionHandshake(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("WebHook-Request-Origin")
+	if origin == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("WebHook-Allowed-Origin", origin)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (rv *Receiver) handleEvent(event cloudEvent) {
+	if !keyVaultEventTypes[event.Type] {
+		return
+	}
+
+	eventsReceived.WithLabelValues(event.Type).Inc()
+
+	vaultBaseURL := vaultBaseURLFromSource(event.Source)
+	if vaultBaseURL == "" {
+		log.Warnf("could not determine vault from event source %s", event.Source)
+		return
+	}
+
+	rv.enqueue(vaultBaseURL, event.Data.ObjectType, event.Data.ObjectName)
+	reconcilesTriggered.WithLabelValues(event.Type).Inc()
+}
+
+// vaultBaseURLFromSource extracts the vault's base URL from a CloudEvents
+// source in the form
+// "/subscriptions/<sub>/resourceGroups/<rg>/providers/Microsoft.KeyVault/vaults/<name>".
+func vaultBaseURLFromSource(source string) string {
+	parts := strings.Split(source, "/")
+	for i, p := range parts {
+		if p == "vaults" && i+1 < len(parts) {
+			return fmt.Sprintf("https://%s.vault.azure.net/", parts[i+1])
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,166 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package eventgrid
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReceiverEnqueuesRecognisedKeyVaultEvent(t *testing.T) {
+	var got []string
+	enqueue := func(vaultBaseURL, objectType, objectName string) {
+		got = []string{vaultBaseURL, objectType, objectName}
+	}
+
+	r := NewReceiver(enqueue, nil)
+
+	body, _ := json.Marshal([]cloudEvent{
+		{
+			ID:     "1",
+			Source: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/my-vault",
+			Type:   "Microsoft.KeyVault.SecretNewVersionCreated",
+			Data:   keyVaultEvent{ObjectType: "Secret", ObjectName: "my-secret"},
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	want := []string{"https://my-vault.vault.azure.net/", "Secret", "my-secret"}
+	if len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("expected enqueue %v, got %v", want, got)
+	}
+}
+
+func TestReceiverIgnoresUnrecognisedEventType(t *testing.T) {
+	called := false
+	enqueue := func(vaultBaseURL, objectType, objectName string) {
+		called = true
+	}
+
+	r := NewReceiver(enqueue, nil)
+
+	body, _ := json.Marshal([]cloudEvent{
+		{ID: "1", Source: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/my-vault", Type: "Microsoft.KeyVault.VaultDeleted"},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if called {
+		t.Fatal("expected enqueue not to be called for an unrecognised event type")
+	}
+}
+
+func TestReceiverRejectsFailedAuth(t *testing.T) {
+	enqueue := func(vaultBaseURL, objectType, objectName string) {
+		t.Fatal("expected enqueue not to be called when verifyAuth fails")
+	}
+
+	verifyAuth := func(r *http.Request) error {
+		return errUnauthorized
+	}
+
+	r := NewReceiver(enqueue, verifyAuth)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("[]")))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+}
+
+func TestReceiverHandlesSubscriptionValidation(t *testing.T) {
+	r := NewReceiver(func(string, string, string) {}, nil)
+
+	body, _ := json.Marshal([]eventGridSchemaEvent{
+		{ID: "1", EventType: "Microsoft.EventGrid.SubscriptionValidationEvent", Data: json.RawMessage(`{"validationCode":"abc123"}`)},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Aeg-Event-Type", "SubscriptionValidation")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp subscriptionValidationResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %s", err.Error())
+	}
+
+	if resp.ValidationResponse != "abc123" {
+		t.Fatalf("expected validationResponse abc123, got %s", resp.ValidationResponse)
+	}
+}
+
+func TestReceiverHandlesAbuseProtectionHandshake(t *testing.T) {
+	r := NewReceiver(func(string, string, string) {}, nil)
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("WebHook-Request-Origin", "eventgrid.azure.net")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	if got := w.Header().Get("WebHook-Allowed-Origin"); got != "eventgrid.azure.net" {
+		t.Fatalf("expected WebHook-Allowed-Origin to echo the request origin, got %q", got)
+	}
+}
+
+func TestReceiverRejectsAbuseProtectionHandshakeMissingOrigin(t *testing.T) {
+	r := NewReceiver(func(string, string, string) {}, nil)
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestVaultBaseURLFromSource(t *testing.T) {
+	got := vaultBaseURLFromSource("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/my-vault")
+	want := "https://my-vault.vault.azure.net/"
+	if got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	if got := vaultBaseURLFromSource("not-a-vault-source"); got != "" {
+		t.Fatalf("expected empty string for an unrecognised source, got %s", got)
+	}
+}
+
+var errUnauthorized = httpError("unauthorized")
+
+type httpError string
+
+func (e httpError) Error() string { return string(e) }
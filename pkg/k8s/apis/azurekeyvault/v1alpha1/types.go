@@ -0,0 +1,114 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 is the version v1alpha1 API for the azurekeyvault group,
+// defining the AzureKeyVaultSecret custom resource the controller watches.
+// +k8s:deepcopy-gen=package
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AzureKeyVaultSecret represents the desired state of a single Key Vault
+// object synced into a Kubernetes Secret.
+type AzureKeyVaultSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AzureKeyVaultSecretSpec   `json:"spec"`
+	Status AzureKeyVaultSecretStatus `json:"status,omitempty"`
+}
+
+// AzureKeyVaultSecretSpec is the desired state of an AzureKeyVaultSecret.
+type AzureKeyVaultSecretSpec struct {
+	Vault  AzureKeyVault `json:"vault"`
+	Output AzureOutput   `json:"output"`
+}
+
+// AzureKeyVault identifies the Key Vault object to sync and, optionally,
+// which Azure identity to authenticate to it with.
+type AzureKeyVault struct {
+	Name   string              `json:"name"`
+	Object AzureKeyVaultObject `json:"object"`
+
+	// Auth overrides the controller-wide Azure identity for this secret
+	// only, so a single controller deployment can serve namespaces bound
+	// to different Azure AD tenants/identities. Nil keeps using the
+	// controller-wide identity.
+	Auth *AzureKeyVaultAuth `json:"auth,omitempty"`
+}
+
+// AzureKeyVaultObject identifies a single object (secret, key or
+// certificate) within a vault.
+type AzureKeyVaultObject struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Version string `json:"version,omitempty"`
+}
+
+// AzureKeyVaultAuth selects the Azure identity used to authenticate to
+// this AzureKeyVaultSecret's vault, overriding the controller-wide
+// default. Exactly one of WorkloadIdentity or SecretRef should be set.
+type AzureKeyVaultAuth struct {
+	WorkloadIdentity *AzureKeyVaultAuthWorkloadIdentity `json:"workloadIdentity,omitempty"`
+	SecretRef        *AzureKeyVaultAuthSecretRef        `json:"secretRef,omitempty"`
+}
+
+// AzureKeyVaultAuthWorkloadIdentity authenticates using the workload
+// identity federated service account token for ClientID/TenantID.
+type AzureKeyVaultAuthWorkloadIdentity struct {
+	ClientID string `json:"clientId"`
+	TenantID string `json:"tenantId"`
+}
+
+// AzureKeyVaultAuthSecretRef authenticates using a service principal
+// stored in a Kubernetes Secret in the given namespace.
+type AzureKeyVaultAuthSecretRef struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// AzureOutput configures the Kubernetes Secret the vault object is synced
+// into.
+type AzureOutput struct {
+	Secret AzureOutputSecret `json:"secret,omitempty"`
+}
+
+// AzureOutputSecret names the target Secret and the data key its value is
+// stored under.
+type AzureOutputSecret struct {
+	Name    string `json:"name,omitempty"`
+	DataKey string `json:"dataKey,omitempty"`
+}
+
+// AzureKeyVaultSecretStatus reports the last observed sync outcome.
+type AzureKeyVaultSecretStatus struct {
+	SecretHash   string      `json:"secretHash,omitempty"`
+	LastSyncTime metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AzureKeyVaultSecretList is a list of AzureKeyVaultSecrets.
+type AzureKeyVaultSecretList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []AzureKeyVaultSecret `json:"items"`
+}
@@ -0,0 +1,80 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sharding lets a fleet of controller replicas split
+// AzureKeyVaultSecrets among themselves by consistent hashing, so a single
+// vault-poll worker is not a scaling bottleneck once a cluster has more
+// secrets than one controller can service in its poll interval.
+package sharding
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Assignment decides which shard index a given AzureKeyVaultSecret
+// (identified by namespace/name) belongs to, out of shardCount total
+// shards.
+type Assignment struct {
+	shardIndex int
+	shardCount int
+}
+
+// NewAssignment builds an Assignment for a replica owning shardIndex out of
+// shardCount total shards. shardIndex must be in [0, shardCount).
+func NewAssignment(shardIndex, shardCount int) (*Assignment, error) {
+	if shardCount < 1 {
+		return nil, fmt.Errorf("shardCount must be at least 1, got %d", shardCount)
+	}
+
+	if shardIndex < 0 || shardIndex >= shardCount {
+		return nil, fmt.Errorf("shardIndex %d out of range for shardCount %d", shardIndex, shardCount)
+	}
+
+	return &Assignment{shardIndex: shardIndex, shardCount: shardCount}, nil
+}
+
+// Owns reports whether the given AzureKeyVaultSecret hashes into this
+// replica's shard bucket. Every replica in the fleet must be constructed
+// with the same shardCount for the buckets to be disjoint and exhaustive.
+func (a *Assignment) Owns(namespace, name string) bool {
+	return bucket(namespace, name, a.shardCount) == a.shardIndex
+}
+
+// bucket picks a shard for namespace/name using rendezvous (highest random
+// weight) hashing: hash the key against every shard index and take the one
+// with the highest score. Unlike a plain hash % shardCount, this means
+// growing or shrinking shardCount only reassigns the ~1/shardCount keys
+// that were scored highest for the changed shard, instead of reshuffling
+// almost everything.
+func bucket(namespace, name string, shardCount int) int {
+	key := namespace + "/" + name
+
+	winner := 0
+	var winnerScore uint32
+	for shard := 0; shard < shardCount; shard++ {
+		h := fnv.New32a()
+		fmt.Fprintf(h, "%s/%d", key, shard)
+		score := h.Sum32()
+
+		if shard == 0 || score > winnerScore {
+			winner = shard
+			winnerScore = score
+		}
+	}
+
+	return winner
+}
@@ -0,0 +1,90 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewAssignmentValidatesRange(t *testing.T) {
+	if _, err := NewAssignment(0, 0); err == nil {
+		t.Fatal("expected error for shardCount 0")
+	}
+
+	if _, err := NewAssignment(2, 2); err == nil {
+		t.Fatal("expected error for shardIndex out of range")
+	}
+
+	if _, err := NewAssignment(1, 2); err != nil {
+		t.Fatalf("expected valid assignment, got error: %s", err.Error())
+	}
+}
+
+func TestOwnsPartitionsExhaustivelyAndDisjointly(t *testing.T) {
+	const shardCount = 4
+
+	assignments := make([]*Assignment, shardCount)
+	for i := 0; i < shardCount; i++ {
+		a, err := NewAssignment(i, shardCount)
+		if err != nil {
+			t.Fatalf("NewAssignment(%d, %d) failed: %s", i, shardCount, err.Error())
+		}
+		assignments[i] = a
+	}
+
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("secret-%d", i)
+
+		owners := 0
+		for _, a := range assignments {
+			if a.Owns("default", name) {
+				owners++
+			}
+		}
+
+		if owners != 1 {
+			t.Fatalf("default/%s was owned by %d shards, want exactly 1", name, owners)
+		}
+	}
+}
+
+// TestGrowingShardCountMovesAMinorityOfKeys is the property that
+// distinguishes rendezvous hashing from a plain hash % shardCount: going
+// from N to N+1 shards should only reassign roughly 1/(N+1) of keys, not
+// reshuffle the whole keyspace.
+func TestGrowingShardCountMovesAMinorityOfKeys(t *testing.T) {
+	const (
+		before = 4
+		after  = 5
+		keys   = 2000
+	)
+
+	moved := 0
+	for i := 0; i < keys; i++ {
+		name := fmt.Sprintf("secret-%d", i)
+		if bucket("default", name, before) != bucket("default", name, after) {
+			moved++
+		}
+	}
+
+	// Expect close to keys/after keys to move; allow generous slack and
+	// just assert it's nowhere near a full reshuffle.
+	if maxMoved := keys / 2; moved > maxMoved {
+		t.Fatalf("growing shard count from %d to %d moved %d/%d keys, want well under %d", before, after, moved, keys, maxMoved)
+	}
+}
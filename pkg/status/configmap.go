@@ -0,0 +1,82 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const statusDataKey = "strategies.json"
+
+// ConfigMapWriter publishes controller status as JSON in a single key of a
+// ConfigMap. It is a lighter-weight alternative to a
+// dedicated AkvControllerStatus CRD for clusters that do not want another
+// CRD installed just to expose this.
+type ConfigMapWriter struct {
+	client    kubernetes.Interface
+	name      string
+	namespace string
+}
+
+// NewConfigMapWriter creates a Writer that upserts the named ConfigMap in
+// namespace.
+func NewConfigMapWriter(client kubernetes.Interface, namespace, name string) *ConfigMapWriter {
+	return &ConfigMapWriter{client: client, name: name, namespace: namespace}
+}
+
+func (w *ConfigMapWriter) Write(strategies []Strategy) error {
+	data, err := json.Marshal(strategies)
+	if err != nil {
+		return fmt.Errorf("failed to marshal controller status, error: %+v", err)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      w.name,
+			Namespace: w.namespace,
+		},
+		Data: map[string]string{
+			statusDataKey: string(data),
+		},
+	}
+
+	configMaps := w.client.CoreV1().ConfigMaps(w.namespace)
+
+	_, err = configMaps.Create(context.Background(), cm, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		existing, getErr := configMaps.Get(context.Background(), w.name, metav1.GetOptions{})
+		if getErr != nil {
+			return fmt.Errorf("failed to upsert controller status configmap %s/%s, error: %+v", w.namespace, w.name, getErr)
+		}
+
+		cm.ResourceVersion = existing.ResourceVersion
+		_, err = configMaps.Update(context.Background(), cm, metav1.UpdateOptions{})
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to upsert controller status configmap %s/%s, error: %+v", w.namespace, w.name, err)
+	}
+
+	return nil
+}
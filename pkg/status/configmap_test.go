@@ -0,0 +1,57 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapWriterWriteTwiceUpdatesInPlace(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	w := NewConfigMapWriter(client, "akv2k8s", "akv2k8s-controller-status")
+
+	first := []Strategy{{Name: StrategyCloudConfig, Status: StatusSuccess, LastUpdateTime: time.Unix(0, 0).UTC()}}
+	if err := w.Write(first); err != nil {
+		t.Fatalf("first Write failed: %s", err.Error())
+	}
+
+	second := []Strategy{{Name: StrategyCloudConfig, Status: StatusError, LastUpdateTime: time.Unix(1, 0).UTC()}}
+	if err := w.Write(second); err != nil {
+		t.Fatalf("second Write failed: %s", err.Error())
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("akv2k8s").Get(context.Background(), "akv2k8s-controller-status", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get configmap: %s", err.Error())
+	}
+
+	var got []Strategy
+	if err := json.Unmarshal([]byte(cm.Data[statusDataKey]), &got); err != nil {
+		t.Fatalf("failed to unmarshal %s: %s", statusDataKey, err.Error())
+	}
+
+	if !reflect.DeepEqual(got, second) {
+		t.Fatalf("second Write left stale data: got %+v, want %+v", got, second)
+	}
+}
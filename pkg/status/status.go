@@ -0,0 +1,128 @@
+/*
+Copyright Sparebanken Vest
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package status builds and publishes the controller status that reports,
+// per startup, which auth and integration strategies the controller
+// actually managed to bring up - borrowed from the Pinniped
+// CredentialIssuerConfig pattern, so operators and downstream tooling can
+// discover working auth modes without scraping logs. The status is
+// published through a Writer; the only Writer in this package is
+// ConfigMapWriter, and no AkvControllerStatus custom resource is defined.
+package status
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StrategyName identifies one of the auth/integration paths the controller
+// can start up with.
+type StrategyName string
+
+const (
+	StrategyCloudConfig      StrategyName = "CloudConfig"
+	StrategyWorkloadIdentity StrategyName = "WorkloadIdentity"
+	StrategyEnvironmentSPN   StrategyName = "EnvironmentSPN"
+	StrategyManagedIdentity  StrategyName = "ManagedIdentity"
+	StrategyDefaultChain     StrategyName = "DefaultChain"
+	StrategyEventGrid        StrategyName = "EventGridReceiver"
+)
+
+// StrategyStatus is Success or Error, mirroring CredentialIssuerConfig's
+// status.strategies[].status.
+type StrategyStatus string
+
+const (
+	StatusSuccess StrategyStatus = "Success"
+	StatusError   StrategyStatus = "Error"
+)
+
+// Strategy is one entry of the published strategies list, shaped like
+// CredentialIssuerConfig's status.strategies[].
+type Strategy struct {
+	Name           StrategyName   `json:"name"`
+	Status         StrategyStatus `json:"status"`
+	Reason         string         `json:"reason"`
+	Message        string         `json:"message"`
+	LastUpdateTime time.Time      `json:"lastUpdateTime"`
+}
+
+// Writer persists the controller's current strategy results somewhere a
+// cluster operator or downstream tool can read them, such as the ConfigMap
+// written by ConfigMapWriter.
+type Writer interface {
+	Write(strategies []Strategy) error
+}
+
+// Reporter accumulates Strategy results during startup and flushes them to
+// a Writer, so a single failed strategy does not need to immediately
+// terminate the process so long as at least one other strategy succeeded.
+type Reporter struct {
+	writer     Writer
+	strategies []Strategy
+}
+
+// NewReporter creates a Reporter that publishes through w. A nil Writer is
+// accepted and simply logs instead, so callers that have not configured a
+// CredentialIssuerConfig-style CRD client still benefit from structured
+// reporting on stdout.
+func NewReporter(w Writer) *Reporter {
+	return &Reporter{writer: w}
+}
+
+// Record adds the outcome of one strategy attempt.
+func (r *Reporter) Record(name StrategyName, err error, now time.Time) {
+	s := Strategy{Name: name, LastUpdateTime: now}
+
+	if err != nil {
+		s.Status = StatusError
+		s.Reason = "Error"
+		s.Message = err.Error()
+	} else {
+		s.Status = StatusSuccess
+		s.Reason = "Success"
+		s.Message = fmt.Sprintf("%s is working", name)
+	}
+
+	r.strategies = append(r.strategies, s)
+}
+
+// AnySucceeded reports whether at least one recorded strategy succeeded.
+func (r *Reporter) AnySucceeded() bool {
+	for _, s := range r.strategies {
+		if s.Status == StatusSuccess {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Flush writes the accumulated strategies through the configured Writer.
+func (r *Reporter) Flush() {
+	if r.writer == nil {
+		for _, s := range r.strategies {
+			log.Infof("strategy %s: %s (%s)", s.Name, s.Status, s.Message)
+		}
+		return
+	}
+
+	if err := r.writer.Write(r.strategies); err != nil {
+		log.Errorf("failed to write controller status: %s", err.Error())
+	}
+}